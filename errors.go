@@ -0,0 +1,68 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package goauto
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Sentinel errors reported by a Pipeline, either directly or wrapped with
+// additional context. ErrWatcherClosed, ErrPathNotFound and
+// ErrRecursionUnsupported are returned by Watch and WatchRecursive;
+// ErrNoWorkflows and ErrEventOverflow are sent on the channel returned by
+// Pipeline.Errors.
+var (
+	ErrWatcherClosed        = errors.New("goauto: watcher is closed")
+	ErrPathNotFound         = errors.New("goauto: watched path not found")
+	ErrRecursionUnsupported = errors.New("goauto: recursive watch not supported for this path")
+	ErrNoWorkflows          = errors.New("goauto: pipeline has no workflows")
+	ErrEventOverflow        = errors.New("goauto: fsnotify event queue overflowed")
+)
+
+// pipelineError associates an underlying error with the pipeline, and
+// optionally the workflow and source path, it occurred in.
+type pipelineError struct {
+	pipeline string
+	workflow string
+	path     string
+	err      error
+}
+
+func (e *pipelineError) Error() string {
+	switch {
+	case e.workflow != "" && e.path != "":
+		return fmt.Sprintf("goauto: pipeline %q workflow %q (%s): %v", e.pipeline, e.workflow, e.path, e.err)
+	case e.path != "":
+		return fmt.Sprintf("goauto: pipeline %q (%s): %v", e.pipeline, e.path, e.err)
+	default:
+		return fmt.Sprintf("goauto: pipeline %q: %v", e.pipeline, e.err)
+	}
+}
+
+// Unwrap lets errors.Is/errors.As see through a pipelineError to the
+// underlying cause.
+func (e *pipelineError) Unwrap() error {
+	return e.err
+}
+
+// wrapWatcherErr classifies a raw fsnotify error, folding in
+// ErrEventOverflow when it looks like the kernel's event queue overran.
+func wrapWatcherErr(err error) error {
+	if strings.Contains(strings.ToLower(err.Error()), "overflow") {
+		return fmt.Errorf("%w: %v", ErrEventOverflow, err)
+	}
+	return err
+}
+
+// workflowName returns the name of wf if it exposes one, or "" otherwise.
+func workflowName(wf Workflower) string {
+	if n, ok := wf.(interface {
+		Name() string
+	}); ok {
+		return n.Name()
+	}
+	return ""
+}
@@ -0,0 +1,51 @@
+// Copyright 2015 Davin Hills. All rights reserved.
+// MIT license. License details can be found in the LICENSE file.
+
+package goauto
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// noopWorkflow is a minimal Workflower that never matches, used only to
+// keep Start from reporting ErrNoWorkflows in TestPipelineErrorsEventOverflow.
+type noopWorkflow struct{}
+
+func (noopWorkflow) Match(fpath string, op uint32) bool { return false }
+func (noopWorkflow) Run(info *TaskInfo) error           { return nil }
+
+// TestPipelineErrorsEventOverflow verifies that a raw fsnotify queue
+// overflow is surfaced on Pipeline.Errors wrapped in ErrEventOverflow.
+func TestPipelineErrorsEventOverflow(t *testing.T) {
+	p := NewPipeline("test", Silent)
+	p.Add(noopWorkflow{})
+	errs := p.Errors()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- p.Start(ctx) }()
+
+	for p.watcher == nil {
+		time.Sleep(time.Millisecond)
+	}
+	p.watcher.Errors <- errors.New("inotify: queue overflow, events dropped")
+
+	select {
+	case err := <-errs:
+		if !errors.Is(err, ErrEventOverflow) {
+			t.Fatalf("expected ErrEventOverflow, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for overflow error")
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Start returned %v", err)
+	}
+}
@@ -4,10 +4,17 @@
 package goauto
 
 import (
+	"archive/zip"
 	"errors"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 )
 
 type goPrjTask struct {
@@ -92,4 +99,275 @@ func (lt goLintTask) Run(info *TaskInfo) (err error) {
 // NewGoLintTask returns a task that will golint the project
 func NewGoLintTask(args ...string) Tasker {
 	return goLintTask{args: args}
-}
\ No newline at end of file
+}
+
+// Stopper is implemented by Taskers that own a long-running child process
+// and need a chance to kill it when a Pipeline is shutting down.
+type Stopper interface {
+	Stop() error
+}
+
+// runGraceDefault is how long a GoRunTask waits after asking a child process
+// to quit before it escalates to SIGKILL.
+const runGraceDefault = 5 * time.Second
+
+// GoRunTask starts a built binary and keeps it running across Run calls,
+// killing off the previous instance (if any) before spawning a fresh one.
+// It is returned by NewGoRunTask as a concrete type, rather than a Tasker,
+// so that SetGracePeriod is actually reachable by callers that want to
+// change it.
+type GoRunTask struct {
+	mu    sync.Mutex
+	bin   string
+	args  []string
+	grace time.Duration
+
+	cmd    *exec.Cmd
+	exited chan struct{}
+}
+
+// NewGoRunTask returns a GoRunTask that runs bin with args as a long-lived
+// child process, wiring its stdout/stderr to the TaskInfo passed to Run.
+// Each subsequent Run gracefully stops the previously started process
+// (SIGTERM, escalating to SIGKILL after a grace period) before starting a
+// new one, so it can be wired into a workflow to hot-restart a service
+// whenever its sources change. The grace period defaults to 5 seconds; call
+// SetGracePeriod on the returned GoRunTask to change it.
+func NewGoRunTask(bin string, args ...string) *GoRunTask {
+	return &GoRunTask{bin: bin, args: args, grace: runGraceDefault}
+}
+
+// SetGracePeriod configures how long Stop waits for the child process to
+// exit after SIGTERM before escalating to SIGKILL.
+func (rt *GoRunTask) SetGracePeriod(d time.Duration) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.grace = d
+}
+
+func (rt *GoRunTask) Run(info *TaskInfo) (err error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	rt.stopLocked()
+
+	fmt.Fprintln(info.Tout, "Go Run", rt.bin, rt.args)
+	cmd := exec.Command(rt.bin, rt.args...)
+	cmd.Stdout = info.Tout
+	cmd.Stderr = info.Terr
+	if err = cmd.Start(); err != nil {
+		return
+	}
+
+	rt.cmd = cmd
+	rt.exited = make(chan struct{})
+	exited := rt.exited
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+	return
+}
+
+// Stop gracefully terminates the running child process, if any. It is
+// safe to call Stop even when no process is currently running, so a
+// Pipeline can invoke it unconditionally during shutdown.
+func (rt *GoRunTask) Stop() error {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.stopLocked()
+	return nil
+}
+
+// stopLocked stops the current child process, if any. Callers must hold rt.mu.
+func (rt *GoRunTask) stopLocked() {
+	if rt.cmd == nil || rt.cmd.Process == nil {
+		return
+	}
+	proc := rt.cmd.Process
+	exited := rt.exited
+	rt.cmd = nil
+	rt.exited = nil
+
+	proc.Signal(syscall.SIGTERM)
+
+	select {
+	case <-exited:
+	case <-time.After(rt.grace):
+		proc.Kill()
+		<-exited
+	}
+}
+
+// Target describes one GOOS/GOARCH(/GOARM) combination to cross-compile for.
+type Target struct {
+	GOOS   string
+	GOARCH string
+	GOARM  string
+}
+
+// String returns the target in "<goos>_<goarch>" form, or
+// "<goos>_<goarch>v<goarm>" when GOARM is set, used to name output folders
+// and archives. The v<goarm> suffix is needed so e.g. linux_arm builds for
+// ARMv6 and ARMv7 don't collide in the same outDir.
+func (t Target) String() string {
+	s := t.GOOS + "_" + t.GOARCH
+	if t.GOARM != "" {
+		s += "v" + t.GOARM
+	}
+	return s
+}
+
+type goCrossBuildTask struct {
+	targets   []Target
+	outDir    string
+	resources []string
+}
+
+// NewGoCrossBuildTask returns a Tasker that cross-compiles the project for
+// each of targets, placing every build in its own folder under outDir
+// named "<name>_<goos>_<goarch>" ("<name>_<goos>_<goarch>v<goarm>" when
+// GOARM is set, see Target.String), copying resources alongside the binary,
+// and zipping each folder up into a .zip of the same name. This lets a
+// watch pipeline produce release-style archives the same way `go build`
+// already produces a local binary.
+func NewGoCrossBuildTask(targets []Target, outDir string, resources []string) Tasker {
+	return goCrossBuildTask{targets: targets, outDir: outDir, resources: resources}
+}
+
+func (ct goCrossBuildTask) Run(info *TaskInfo) (err error) {
+	info.Target = info.Src
+	dir := GoRelSrcDir(info.Src)
+	name := filepath.Base(dir)
+
+	for _, t := range ct.targets {
+		fmt.Fprintln(info.Tout, "Go Cross Build", t, "...", dir)
+		if err = ct.buildTarget(info, dir, name, t); err != nil {
+			return
+		}
+		fmt.Fprintln(info.Tout, "ok")
+	}
+	return
+}
+
+// buildTarget builds, packages and archives a single Target.
+func (ct goCrossBuildTask) buildTarget(info *TaskInfo, dir, name string, t Target) error {
+	binName := name + "_" + t.String()
+	if t.GOOS == "windows" {
+		binName += ".exe"
+	}
+
+	targetDir := filepath.Join(ct.outDir, name+"_"+t.String())
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return err
+	}
+
+	info.Buf.Reset()
+	cmd := exec.Command("go", "build", "-o", filepath.Join(targetDir, binName), dir)
+	cmd.Env = append(os.Environ(), "GOOS="+t.GOOS, "GOARCH="+t.GOARCH, "GOARM="+t.GOARM)
+	cmd.Stdout = &info.Buf
+	cmd.Stderr = info.Terr
+	defer func() {
+		info.Buf.WriteTo(info.Tout)
+	}()
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+
+	for _, res := range ct.resources {
+		if err := copyResource(res, targetDir); err != nil {
+			return err
+		}
+	}
+
+	archive := filepath.Join(ct.outDir, name+"_"+t.String()+".zip")
+	return zipDir(targetDir, archive)
+}
+
+// copyResource copies the file or directory at src into destDir, preserving
+// its base name.
+func copyResource(src, destDir string) error {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if fi.IsDir() {
+		return filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(src, path)
+			if err != nil {
+				return err
+			}
+			dest := filepath.Join(destDir, filepath.Base(src), rel)
+			if info.IsDir() {
+				return os.MkdirAll(dest, 0755)
+			}
+			return copyFile(path, dest)
+		})
+	}
+	return copyFile(src, filepath.Join(destDir, filepath.Base(src)))
+}
+
+// copyFile copies a single file from src to dest, creating dest's parent
+// directory and preserving src's permissions.
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fi.Mode())
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	_, err = io.Copy(out, in)
+	return err
+}
+
+// zipDir archives the contents of srcDir into a new zip file at archive.
+func zipDir(srcDir, archive string) error {
+	f, err := os.Create(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	defer zw.Close()
+
+	return filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		w, err := zw.Create(rel)
+		if err != nil {
+			return err
+		}
+		in, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		_, err = io.Copy(w, in)
+		return err
+	})
+}
@@ -4,10 +4,17 @@
 package goauto
 
 import (
+	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"gopkg.in/fsnotify.v1"
@@ -37,23 +44,99 @@ type Pipeline struct {
 	Wout, Werr io.Writer
 	Workflows  []Workflower
 	Verbose    bool
-	watcher    *fsnotify.Watcher
-	events     chan []fsnotify.Event
-	done       chan bool
-	recDirs    map[string]bool
+	// BatchWindow is how often accumulated events are flushed for dispatch.
+	// Defaults to batchTick when zero.
+	BatchWindow time.Duration
+	// Debounce is how long a path must stay quiet before its coalesced
+	// event is dispatched, so an editor's save flurry (rename+create+
+	// chmod+write) fires a workflow once instead of repeatedly. Defaults
+	// to batchTick when zero.
+	Debounce time.Duration
+	watcher  *fsnotify.Watcher
+	events   chan []fsnotify.Event
+	recDirs  map[string]bool
+	ignores  []ignoreRule
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+	errs     chan error
+	closed   int32 // set with atomic.StoreInt32, read with atomic.LoadInt32
+	stoppers []Stopper
+}
+
+// errsBacklog is how many errors Pipeline.Errors buffers before sendErr
+// starts dropping them rather than blocking the caller.
+const errsBacklog = 16
+
+// Errors returns a channel on which the Pipeline reports fsnotify errors
+// and workflow failures as they happen. Errors is safe to call before
+// Start, the usual pattern being to grab the channel and range over it in
+// a separate goroutine before starting the Pipeline.
+func (p *Pipeline) Errors() <-chan error {
+	if p.errs == nil {
+		p.errs = make(chan error, errsBacklog)
+	}
+	return p.errs
+}
+
+// sendErr delivers err on the Errors channel without blocking; if nothing
+// is listening and the channel is full, the error is dropped.
+func (p *Pipeline) sendErr(err error) {
+	if p.errs == nil {
+		return
+	}
+	select {
+	case p.errs <- err:
+	default:
+	}
+}
+
+// ignoreRule is a single compiled exclude pattern, parsed with .gitignore
+// semantics (glob syntax, leading "!" negates, trailing "/" is dir-only,
+// a "/" anywhere but the end anchors the pattern to a Watch root).
+type ignoreRule struct {
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// parseIgnoreLine compiles one gitignore-style pattern line into a rule.
+func parseIgnoreLine(line string) ignoreRule {
+	r := ignoreRule{pattern: line}
+	if strings.HasPrefix(r.pattern, "!") {
+		r.negate = true
+		r.pattern = r.pattern[1:]
+	}
+	if strings.HasSuffix(r.pattern, "/") {
+		r.dirOnly = true
+		r.pattern = strings.TrimSuffix(r.pattern, "/")
+	}
+	if strings.HasPrefix(r.pattern, "/") {
+		r.anchored = true
+		r.pattern = strings.TrimPrefix(r.pattern, "/")
+	} else if strings.Contains(r.pattern, "/") {
+		// A "/" anywhere but the end also anchors the pattern, per
+		// .gitignore semantics, even without a leading "/".
+		r.anchored = true
+	}
+	return r
 }
 
 // NewPipeline returns a basic Pipeline with a dir to watch, output and error writers and a workflow
 func NewPipeline(name string, verbose bool) *Pipeline {
-	p := Pipeline{Name: name, Wout: os.Stdout, Werr: os.Stderr, Verbose: verbose}
+	p := Pipeline{Name: name, Wout: os.Stdout, Werr: os.Stderr, Verbose: verbose, errs: make(chan error, errsBacklog)}
 	return &p
 }
 
 // Watch adds a GOPATH relative or absolute path to watch
 // rejects invalid paths and ignores duplicates
 func (p *Pipeline) Watch(watchDir string) (string, error) {
+	if atomic.LoadInt32(&p.closed) != 0 {
+		return "", ErrWatcherClosed
+	}
 	d, err := AbsPath(watchDir)
 	if err != nil {
+		err = fmt.Errorf("%w: %v", ErrPathNotFound, err)
 		if p.Verbose {
 			fmt.Fprintln(p.Wout, err)
 		}
@@ -74,9 +157,15 @@ func (p *Pipeline) Watch(watchDir string) (string, error) {
 
 // WatchRecursive adds a GOPATH relative or absolute path to watch recursivly
 func (p *Pipeline) WatchRecursive(watchDir string, ignoreHidden bool) error {
+	if atomic.LoadInt32(&p.closed) != 0 {
+		return ErrWatcherClosed
+	}
 	d, err := AbsPath(watchDir)
 	if err != nil {
-		return err
+		return fmt.Errorf("%w: %v", ErrPathNotFound, err)
+	}
+	if fi, err := os.Stat(d); err != nil || !fi.IsDir() {
+		return fmt.Errorf("%w: %s", ErrRecursionUnsupported, d)
 	}
 	if p.recDirs == nil {
 		p.recDirs = make(map[string]bool)
@@ -90,6 +179,9 @@ func (p *Pipeline) WatchRecursive(watchDir string, ignoreHidden bool) error {
 			if IsHidden(info.Name()) && ignoreHidden {
 				return filepath.SkipDir
 			}
+			if p.ignored(path, true) {
+				return filepath.SkipDir
+			}
 			p.Watch(path)
 		}
 		return nil
@@ -97,6 +189,131 @@ func (p *Pipeline) WatchRecursive(watchDir string, ignoreHidden bool) error {
 	return nil
 }
 
+// Exclude adds a gitignore-style glob pattern to the set of paths Pipeline
+// will not watch or dispatch events for. A leading "!" negates a pattern
+// matched by an earlier rule and a trailing "/" restricts the rule to
+// directories, mirroring .gitignore semantics.
+func (p *Pipeline) Exclude(pattern string) {
+	p.ignores = append(p.ignores, parseIgnoreLine(pattern))
+}
+
+// ExcludeFromGitignore reads path (typically a project's .gitignore) and
+// Excludes each of its patterns, skipping blank lines and comments.
+func (p *Pipeline) ExcludeFromGitignore(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		p.Exclude(line)
+	}
+	return scanner.Err()
+}
+
+// ignored reports whether fpath is excluded by the Pipeline's ignore rules.
+// An unanchored pattern (no "/", other than perhaps a trailing one) may
+// match any path segment, so e.g. "node_modules/" drops every file
+// anywhere under a node_modules directory, not just the directory entry
+// itself. An anchored pattern (leading "/", or a "/" elsewhere in the
+// pattern) is matched against fpath's segments relative to whichever
+// Watch root contains it, as in a real .gitignore, with "**" matching
+// zero or more segments. Rules are applied in order so a later negated
+// rule can un-ignore a path matched by an earlier one.
+func (p *Pipeline) ignored(fpath string, isDir bool) bool {
+	if len(p.ignores) == 0 {
+		return false
+	}
+	segs := strings.Split(strings.Trim(filepath.ToSlash(fpath), "/"), "/")
+	rootSegs := p.rootRelSegs(fpath, segs)
+
+	ign := false
+	for _, r := range p.ignores {
+		if r.matches(rootSegs, segs, isDir) {
+			ign = !r.negate
+		}
+	}
+	return ign
+}
+
+// rootRelSegs returns fpath's path segments relative to whichever Watch
+// root contains it, for evaluating anchored ignore patterns against the
+// watched tree rather than the filesystem root. It falls back to segs,
+// fpath's absolute segments, if fpath isn't under any Watch root yet (e.g.
+// the initial WatchRecursive walk of dir d calls ignored on d itself before
+// d is appended to p.Watches).
+func (p *Pipeline) rootRelSegs(fpath string, segs []string) []string {
+	abs := filepath.ToSlash(fpath)
+	for _, w := range p.Watches {
+		wslash := filepath.ToSlash(w)
+		if abs == wslash {
+			return nil
+		}
+		if rel := strings.TrimPrefix(abs, wslash+"/"); rel != abs {
+			return strings.Split(rel, "/")
+		}
+	}
+	return segs
+}
+
+// matches reports whether r matches fpath, given its segments relative to
+// a Watch root (rootSegs, used for anchored patterns) and its absolute
+// segments (segs, used for unanchored patterns). isDir indicates whether
+// fpath itself is a directory.
+func (r ignoreRule) matches(rootSegs, segs []string, isDir bool) bool {
+	if !r.anchored {
+		pat := r.pattern
+		for i, seg := range segs {
+			if r.dirOnly && i == len(segs)-1 && !isDir {
+				continue // a dirOnly rule never matches the file itself
+			}
+			if ok, _ := filepath.Match(pat, seg); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	pat := strings.Split(r.pattern, "/")
+	for end := 0; end <= len(rootSegs); end++ {
+		if !matchSegments(pat, rootSegs[:end]) {
+			continue
+		}
+		if end < len(rootSegs) {
+			return true // pat matched an ancestor directory of fpath
+		}
+		return !r.dirOnly || isDir
+	}
+	return false
+}
+
+// matchSegments reports whether pat matches segs exactly, treating "**"
+// as zero or more path segments rather than exactly one.
+func matchSegments(pat, segs []string) bool {
+	if len(pat) == 0 {
+		return len(segs) == 0
+	}
+	if pat[0] == "**" {
+		if matchSegments(pat[1:], segs) {
+			return true
+		}
+		return len(segs) > 0 && matchSegments(pat, segs[1:])
+	}
+	if len(segs) == 0 {
+		return false
+	}
+	if ok, _ := filepath.Match(pat[0], segs[0]); !ok {
+		return false
+	}
+	return matchSegments(pat[1:], segs[1:])
+}
+
 // recDir checks if an event is adding or renaming a directory in a recursive watch
 func (p *Pipeline) recDir(e fsnotify.Event) {
 	if dirOps&e.Op != e.Op {
@@ -105,6 +322,9 @@ func (p *Pipeline) recDir(e fsnotify.Event) {
 	if fi, err := os.Stat(e.Name); err != nil || !fi.IsDir() {
 		return
 	}
+	if p.ignored(e.Name, true) {
+		return
+	}
 
 	h := IsHidden(e.Name)
 	for dir, iHidden := range p.recDirs {
@@ -125,33 +345,87 @@ func (p *Pipeline) Add(ws ...Workflower) {
 	}
 }
 
-// batchRun watches for file events and batches them up based on a timer
+// AddStopper registers one or more Stoppers, typically Taskers returned by
+// NewGoRunTask, to be stopped when the Pipeline shuts down. This is the
+// reliable way to reach a long-running child process at shutdown: a
+// Workflower's Taskers aren't otherwise visible to the Pipeline, so a task
+// given only to a Workflow would never be signalled.
+func (p *Pipeline) AddStopper(stoppers ...Stopper) {
+	p.stoppers = append(p.stoppers, stoppers...)
+}
+
+// pendingEvent tracks the coalesced Op and most recent sighting for a path
+// that batchRun has seen an event for but not yet flushed.
+type pendingEvent struct {
+	op       fsnotify.Op
+	lastSeen time.Time
+}
+
+// batchRun watches for file events and batches them up based on a timer.
+// Multiple events for the same path within a batch window are coalesced
+// into a single event carrying the union of their ops, and a path is only
+// flushed once it has been quiet for Pipeline.Debounce, so an editor's
+// atomic-save flurry (rename+create+chmod+write) dispatches once instead
+// of once per fsnotify event.
 // **Thanks to github.com/egonelbre for the suggestions and examples for batch events
-func (p *Pipeline) batchRun() {
-	tick := time.Tick(batchTick)
-	var evs []fsnotify.Event
+func (p *Pipeline) batchRun(ctx context.Context) {
+	window := p.BatchWindow
+	if window <= 0 {
+		window = batchTick
+	}
+	debounce := p.Debounce
+	if debounce <= 0 {
+		debounce = batchTick
+	}
+
+	tick := time.Tick(window)
+	pending := make(map[string]*pendingEvent)
 
-outer:
 	for {
 		select {
 		case event := <-p.watcher.Events:
-			evs = append(evs, event)
+			if pe, ok := pending[event.Name]; ok {
+				pe.op |= event.Op
+				pe.lastSeen = time.Now()
+			} else {
+				pending[event.Name] = &pendingEvent{op: event.Op, lastSeen: time.Now()}
+			}
 		case <-tick:
-			if len(evs) == 0 {
+			if len(pending) == 0 {
 				continue
 			}
-			p.events <- evs
-			evs = []fsnotify.Event{}
-		case <-p.done:
-			break outer
+			now := time.Now()
+			var evs []fsnotify.Event
+			for name, pe := range pending {
+				if now.Sub(pe.lastSeen) < debounce {
+					continue // still within the quiet period, give it another tick
+				}
+				delete(pending, name)
+				if _, err := os.Stat(name); err != nil && pe.op&fsnotify.Remove == 0 {
+					continue // transient file (e.g. an editor temp file) is already gone
+				}
+				evs = append(evs, fsnotify.Event{Name: name, Op: pe.op})
+			}
+			if len(evs) > 0 {
+				select {
+				case p.events <- evs:
+				case <-ctx.Done():
+					return
+				}
+			}
+		case <-ctx.Done():
+			return
 		}
 	}
-	close(p.done)
 }
 
-// Start begins watching for changes to files in the Watches directories
-// Detected file changes will be compared with workflow regexp and if match will run the workflow tasks
-func (p *Pipeline) Start() {
+// Start begins watching for changes to files in the Watches directories.
+// Detected file changes will be compared with workflow regexp and if match
+// will run the workflow tasks. Start blocks until ctx is cancelled, at
+// which point it closes the watcher, waits for any in-flight workflow to
+// finish and returns nil. If the fsnotify watcher cannot be created, Start
+// returns that error at once.
+func (p *Pipeline) Start(ctx context.Context) error {
 	if p.Wout == nil {
 		p.Wout = os.Stdout
 	}
@@ -162,28 +436,37 @@ func (p *Pipeline) Start() {
 		p.Name = "<UNNAMED>"
 	}
 
+	if p.errs == nil {
+		p.errs = make(chan error, errsBacklog)
+	}
+
 	if len(p.Watches) < 1 {
 		fmt.Fprintln(p.Werr, "Pipeline", p.Name, "is not watching anything")
 	}
 
 	if len(p.Workflows) < 1 {
 		fmt.Fprintln(p.Werr, "Pipeline", p.Name, "has no Workflows")
+		p.sendErr(ErrNoWorkflows)
 	}
 
 	// Create a watcher
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		fmt.Fprintln(p.Werr, err)
-		return
+		p.sendErr(err)
+		return err
 	}
 	p.watcher = watcher
 
-	// Make the channels to batch the events and signal done
-	p.done = make(chan bool)
+	ctx, cancel := context.WithCancel(ctx)
+	p.cancel = cancel
+	defer cancel()
+
+	// Make the channel to batch the events
 	p.events = make(chan []fsnotify.Event)
 
 	// start watching
-	go p.batchRun()
+	go p.batchRun(ctx)
 
 	// Add the watch directories to the watcher
 	for _, w := range p.Watches {
@@ -193,7 +476,7 @@ func (p *Pipeline) Start() {
 		}
 	}
 
-	// block and wait to receive batched events
+	// block and wait to receive batched events until ctx is cancelled
 	for {
 		select {
 		case evs := <-p.events:
@@ -201,24 +484,79 @@ func (p *Pipeline) Start() {
 				go p.recDir(e)
 				p.queryWorkflow(e.Name, uint32(e.Op))
 			}
+		case err := <-p.watcher.Errors:
+			p.sendErr(wrapWatcherErr(err))
+		case <-ctx.Done():
+			atomic.StoreInt32(&p.closed, 1)
+			p.watcher.Close()
+			p.wg.Wait()
+			p.stopTasks()
+			return nil
 		}
 	}
 }
 
-// queryWorkflow checks for file match for each workflow and if matches executes the workflow tasks
+// stopTasks calls Stop on every registered Stopper (see AddStopper), giving
+// long-running child processes (such as one started by NewGoRunTask) a
+// chance to exit before Start returns.
+func (p *Pipeline) stopTasks() {
+	for _, s := range p.stoppers {
+		s.Stop()
+	}
+}
+
+// RunUntilSignal starts the pipeline and blocks until one of sigs (SIGINT
+// and SIGTERM if none are given) is received, at which point it stops
+// watching, waits for any in-flight workflow to finish and returns.
+func (p *Pipeline) RunUntilSignal(sigs ...os.Signal) error {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, sigs...)
+	defer signal.Stop(sigc)
+
+	go func() {
+		<-sigc
+		cancel()
+	}()
+
+	return p.Start(ctx)
+}
+
+// queryWorkflow checks for file match for each workflow and if matches
+// executes the workflow tasks. Matching workflows are run serially, in
+// Workflows order, so the same workflow never has two runs overlapping;
+// the WaitGroup still tracks them so Start's shutdown path can wait for
+// the last one to finish.
 func (p *Pipeline) queryWorkflow(fpath string, op uint32) {
+	if p.ignored(fpath, false) {
+		return
+	}
 	if p.Verbose {
 		fmt.Fprintf(p.Wout, "Watcher event %v %v\n", fpath, op)
 	}
 	for _, wf := range p.Workflows {
 		if wf.Match(fpath, op) {
-			wf.Run(&TaskInfo{Src: fpath, Tout: p.Wout, Terr: p.Werr, Verbose: p.Verbose})
+			p.wg.Add(1)
+			func(wf Workflower) {
+				defer p.wg.Done()
+				if err := wf.Run(&TaskInfo{Src: fpath, Tout: p.Wout, Terr: p.Werr, Verbose: p.Verbose}); err != nil {
+					p.sendErr(&pipelineError{pipeline: p.Name, workflow: workflowName(wf), path: fpath, err: err})
+				}
+			}(wf)
 		}
 	}
 }
 
-// Stop will discontinue watching for file changes
+// Stop discontinues watching for file changes. It is safe to call even if
+// the Pipeline was never started.
 func (p *Pipeline) Stop() {
-	p.done <- true
-	p.watcher.Close()
+	if p.cancel != nil {
+		p.cancel()
+	}
 }